@@ -0,0 +1,379 @@
+// Package cache provides a local SQLite mirror of a Pocket account's
+// retrieved items, so that listing and filtering can run offline and
+// without re-fetching the whole list on every invocation.
+package cache
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/motemen/go-pocket/api"
+	_ "modernc.org/sqlite"
+)
+
+// Store is a local cache of Pocket items, backed by a SQLite database.
+type Store struct {
+	db *sql.DB
+}
+
+// Open opens the cache database at path, creating it and its schema if
+// necessary.
+func Open(path string) (*Store, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Store{db: db}
+	if err := s.migrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Close closes the underlying database.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+func (s *Store) migrate() error {
+	_, err := s.db.Exec(`
+CREATE TABLE IF NOT EXISTS items (
+	item_id    INTEGER PRIMARY KEY,
+	title      TEXT NOT NULL,
+	url        TEXT NOT NULL,
+	domain     TEXT NOT NULL,
+	tags       TEXT NOT NULL,
+	authors    TEXT NOT NULL,
+	time_added INTEGER NOT NULL,
+	sort_id    INTEGER NOT NULL,
+	data       TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS items_domain_idx ON items(domain);
+CREATE INDEX IF NOT EXISTS items_tags_idx ON items(tags);
+
+CREATE TABLE IF NOT EXISTS sync_state (
+	key   TEXT PRIMARY KEY,
+	value TEXT NOT NULL
+);
+`)
+	return err
+}
+
+// Since returns the "since" timestamp recorded during the previous sync, or
+// 0 if the cache has never been synced.
+func (s *Store) Since() (int, error) {
+	value, err := s.state("since")
+	if err != nil || value == "" {
+		return 0, err
+	}
+	return strconv.Atoi(value)
+}
+
+// SyncedAt returns the time of the previous sync, or the zero Time if the
+// cache has never been synced.
+func (s *Store) SyncedAt() (time.Time, error) {
+	value, err := s.state("synced_at")
+	if err != nil || value == "" {
+		return time.Time{}, err
+	}
+
+	unix, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return time.Unix(unix, 0), nil
+}
+
+func (s *Store) state(key string) (string, error) {
+	var value string
+	err := s.db.QueryRow(`SELECT value FROM sync_state WHERE key = ?`, key).Scan(&value)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return value, err
+}
+
+// Merge applies a retrieve response to the cache: items that are still
+// unread are upserted, items that have since been archived or deleted are
+// dropped, and the since/synced_at markers are advanced so the next refresh
+// only fetches the delta.
+func (s *Store) Merge(res *api.RetrieveResult, syncedAt time.Time) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	upsert, err := tx.Prepare(`
+INSERT INTO items (item_id, title, url, domain, tags, authors, time_added, sort_id, data)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT(item_id) DO UPDATE SET
+	title = excluded.title,
+	url = excluded.url,
+	domain = excluded.domain,
+	tags = excluded.tags,
+	authors = excluded.authors,
+	time_added = excluded.time_added,
+	sort_id = excluded.sort_id,
+	data = excluded.data
+`)
+	if err != nil {
+		return err
+	}
+	defer upsert.Close()
+
+	remove, err := tx.Prepare(`DELETE FROM items WHERE item_id = ?`)
+	if err != nil {
+		return err
+	}
+	defer remove.Close()
+
+	for _, item := range res.List {
+		if item.Status != api.ItemStatusUnread {
+			if _, err := remove.Exec(item.ItemID); err != nil {
+				return err
+			}
+			continue
+		}
+
+		data, err := encodeItem(item)
+		if err != nil {
+			return err
+		}
+
+		if _, err := upsert.Exec(
+			item.ItemID,
+			item.Title(),
+			item.URL(),
+			domainOf(item.URL()),
+			joinNames(item.Tags),
+			joinAuthorNames(item.Authors),
+			time.Time(item.TimeAdded).Unix(),
+			item.SortId,
+			string(data),
+		); err != nil {
+			return err
+		}
+	}
+
+	if err := setState(tx, "since", strconv.Itoa(res.Since)); err != nil {
+		return err
+	}
+	if err := setState(tx, "synced_at", strconv.FormatInt(syncedAt.Unix(), 10)); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func setState(tx *sql.Tx, key, value string) error {
+	_, err := tx.Exec(`
+INSERT INTO sync_state (key, value) VALUES (?, ?)
+ON CONFLICT(key) DO UPDATE SET value = excluded.value
+`, key, value)
+	return err
+}
+
+// Get looks up a single cached item by ID.
+func (s *Store) Get(itemID int) (api.Item, bool, error) {
+	var data string
+	err := s.db.QueryRow(`SELECT data FROM items WHERE item_id = ?`, itemID).Scan(&data)
+	if err == sql.ErrNoRows {
+		return api.Item{}, false, nil
+	}
+	if err != nil {
+		return api.Item{}, false, err
+	}
+
+	item, err := decodeItem([]byte(data))
+	if err != nil {
+		return api.Item{}, false, err
+	}
+
+	return item, true, nil
+}
+
+// ListOptions filters the items returned by List.
+type ListOptions struct {
+	Domain string
+	Tag    string
+	Search string
+	Count  int
+}
+
+// List returns cached items matching opts, ordered the same way Pocket's
+// retrieve API orders them (by sort_id).
+func (s *Store) List(opts ListOptions) ([]api.Item, error) {
+	query := `SELECT data FROM items WHERE 1 = 1`
+	var args []interface{}
+
+	if opts.Domain != "" {
+		query += ` AND domain = ?`
+		args = append(args, opts.Domain)
+	}
+	if opts.Tag != "" {
+		query += ` AND (',' || tags || ',') LIKE ?`
+		args = append(args, "%,"+opts.Tag+",%")
+	}
+	if opts.Search != "" {
+		query += ` AND (title LIKE ? OR url LIKE ?)`
+		like := "%" + opts.Search + "%"
+		args = append(args, like, like)
+	}
+
+	query += ` ORDER BY sort_id`
+
+	if opts.Count > 0 {
+		query += ` LIMIT ?`
+		args = append(args, opts.Count)
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []api.Item
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+
+		item, err := decodeItem([]byte(data))
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+// storedItem is api.Item's field set with the Time fields flattened to unix
+// timestamps. api.Time only implements UnmarshalJSON, not MarshalJSON, so
+// round-tripping an api.Item through encoding/json directly loses those
+// fields; storedItem is what actually gets persisted as the items.data blob.
+type storedItem struct {
+	ItemID        int
+	ResolvedId    int
+	GivenURL      string
+	ResolvedURL   string
+	GivenTitle    string
+	ResolvedTitle string
+	Favorite      int
+	Status        api.ItemStatus
+	Excerpt       string
+	IsArticle     int
+	HasImage      api.ItemMediaAttachment
+	HasVideo      api.ItemMediaAttachment
+	WordCount     int
+
+	Tags    map[string]map[string]interface{}
+	Authors map[string]map[string]interface{}
+	Images  map[string]map[string]interface{}
+	Videos  map[string]map[string]interface{}
+
+	SortId        int
+	TimeAdded     int64
+	TimeUpdated   int64
+	TimeRead      int64
+	TimeFavorited int64
+}
+
+func encodeItem(item api.Item) ([]byte, error) {
+	return json.Marshal(storedItem{
+		ItemID:        item.ItemID,
+		ResolvedId:    item.ResolvedId,
+		GivenURL:      item.GivenURL,
+		ResolvedURL:   item.ResolvedURL,
+		GivenTitle:    item.GivenTitle,
+		ResolvedTitle: item.ResolvedTitle,
+		Favorite:      item.Favorite,
+		Status:        item.Status,
+		Excerpt:       item.Excerpt,
+		IsArticle:     item.IsArticle,
+		HasImage:      item.HasImage,
+		HasVideo:      item.HasVideo,
+		WordCount:     item.WordCount,
+		Tags:          item.Tags,
+		Authors:       item.Authors,
+		Images:        item.Images,
+		Videos:        item.Videos,
+		SortId:        item.SortId,
+		TimeAdded:     time.Time(item.TimeAdded).Unix(),
+		TimeUpdated:   time.Time(item.TimeUpdated).Unix(),
+		TimeRead:      time.Time(item.TimeRead).Unix(),
+		TimeFavorited: time.Time(item.TimeFavorited).Unix(),
+	})
+}
+
+func decodeItem(data []byte) (api.Item, error) {
+	var s storedItem
+	if err := json.Unmarshal(data, &s); err != nil {
+		return api.Item{}, err
+	}
+
+	return api.Item{
+		ItemID:        s.ItemID,
+		ResolvedId:    s.ResolvedId,
+		GivenURL:      s.GivenURL,
+		ResolvedURL:   s.ResolvedURL,
+		GivenTitle:    s.GivenTitle,
+		ResolvedTitle: s.ResolvedTitle,
+		Favorite:      s.Favorite,
+		Status:        s.Status,
+		Excerpt:       s.Excerpt,
+		IsArticle:     s.IsArticle,
+		HasImage:      s.HasImage,
+		HasVideo:      s.HasVideo,
+		WordCount:     s.WordCount,
+		Tags:          s.Tags,
+		Authors:       s.Authors,
+		Images:        s.Images,
+		Videos:        s.Videos,
+		SortId:        s.SortId,
+		TimeAdded:     api.Time(time.Unix(s.TimeAdded, 0)),
+		TimeUpdated:   api.Time(time.Unix(s.TimeUpdated, 0)),
+		TimeRead:      api.Time(time.Unix(s.TimeRead, 0)),
+		TimeFavorited: api.Time(time.Unix(s.TimeFavorited, 0)),
+	}, nil
+}
+
+func domainOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+func joinNames(names map[string]map[string]interface{}) string {
+	names2 := make([]string, 0, len(names))
+	for name := range names {
+		names2 = append(names2, name)
+	}
+	sort.Strings(names2)
+	return strings.Join(names2, ",")
+}
+
+func joinAuthorNames(authors map[string]map[string]interface{}) string {
+	names := make([]string, 0, len(authors))
+	for _, author := range authors {
+		if name, ok := author["name"].(string); ok && name != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
@@ -0,0 +1,145 @@
+package cache
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/motemen/go-pocket/api"
+)
+
+func openTestStore(t *testing.T) *Store {
+	t.Helper()
+
+	store, err := Open(filepath.Join(t.TempDir(), "cache.db"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	return store
+}
+
+func unreadItem(id int, title string) api.Item {
+	return api.Item{
+		ItemID:        id,
+		GivenURL:      "https://example.com/" + title,
+		ResolvedTitle: title,
+		Status:        api.ItemStatusUnread,
+		SortId:        id,
+	}
+}
+
+func TestMergeUpsertsUnreadItems(t *testing.T) {
+	store := openTestStore(t)
+
+	res := &api.RetrieveResult{
+		List:  map[string]api.Item{"1": unreadItem(1, "first"), "2": unreadItem(2, "second")},
+		Since: 100,
+	}
+	syncedAt := time.Unix(1700000000, 0)
+
+	if err := store.Merge(res, syncedAt); err != nil {
+		t.Fatalf("Merge: %v", err)
+	}
+
+	items, err := store.List(ListOptions{})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(items) != 2 {
+		t.Fatalf("List returned %d items, want 2", len(items))
+	}
+
+	since, err := store.Since()
+	if err != nil {
+		t.Fatalf("Since: %v", err)
+	}
+	if since != 100 {
+		t.Errorf("Since() = %d, want 100", since)
+	}
+
+	gotSyncedAt, err := store.SyncedAt()
+	if err != nil {
+		t.Fatalf("SyncedAt: %v", err)
+	}
+	if !gotSyncedAt.Equal(syncedAt) {
+		t.Errorf("SyncedAt() = %v, want %v", gotSyncedAt, syncedAt)
+	}
+}
+
+func TestMergeDropsItemOnArchiveTransition(t *testing.T) {
+	store := openTestStore(t)
+
+	item := unreadItem(1, "first")
+	if err := store.Merge(&api.RetrieveResult{List: map[string]api.Item{"1": item}, Since: 1}, time.Unix(1, 0)); err != nil {
+		t.Fatalf("Merge (unread): %v", err)
+	}
+
+	if _, ok, err := store.Get(1); err != nil || !ok {
+		t.Fatalf("Get after unread merge: ok=%v err=%v, want ok=true", ok, err)
+	}
+
+	archived := item
+	archived.Status = api.ItemStatusArchived
+	if err := store.Merge(&api.RetrieveResult{List: map[string]api.Item{"1": archived}, Since: 2}, time.Unix(2, 0)); err != nil {
+		t.Fatalf("Merge (archive): %v", err)
+	}
+
+	_, ok, err := store.Get(1)
+	if err != nil {
+		t.Fatalf("Get after archive merge: %v", err)
+	}
+	if ok {
+		t.Errorf("Get found item %d after it was archived, want it dropped from the cache", 1)
+	}
+
+	since, err := store.Since()
+	if err != nil {
+		t.Fatalf("Since: %v", err)
+	}
+	if since != 2 {
+		t.Errorf("Since() = %d, want 2 (high-water mark from the second merge)", since)
+	}
+}
+
+func TestListFilters(t *testing.T) {
+	store := openTestStore(t)
+
+	a := unreadItem(1, "golang-tips")
+	a.GivenURL = "https://blog.golang.org/tips"
+	a.Tags = map[string]map[string]interface{}{"go": {}}
+
+	b := unreadItem(2, "cooking-basics")
+	b.GivenURL = "https://food.example.com/basics"
+	b.Tags = map[string]map[string]interface{}{"food": {}}
+
+	res := &api.RetrieveResult{List: map[string]api.Item{"1": a, "2": b}, Since: 1}
+	if err := store.Merge(res, time.Unix(1, 0)); err != nil {
+		t.Fatalf("Merge: %v", err)
+	}
+
+	byDomain, err := store.List(ListOptions{Domain: "blog.golang.org"})
+	if err != nil {
+		t.Fatalf("List(Domain): %v", err)
+	}
+	if len(byDomain) != 1 || byDomain[0].ItemID != 1 {
+		t.Errorf("List(Domain=blog.golang.org) = %+v, want only item 1", byDomain)
+	}
+
+	byTag, err := store.List(ListOptions{Tag: "food"})
+	if err != nil {
+		t.Fatalf("List(Tag): %v", err)
+	}
+	if len(byTag) != 1 || byTag[0].ItemID != 2 {
+		t.Errorf("List(Tag=food) = %+v, want only item 2", byTag)
+	}
+
+	bySearch, err := store.List(ListOptions{Search: "cooking"})
+	if err != nil {
+		t.Fatalf("List(Search): %v", err)
+	}
+	if len(bySearch) != 1 || bySearch[0].ItemID != 2 {
+		t.Errorf("List(Search=cooking) = %+v, want only item 2", bySearch)
+	}
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/motemen/go-pocket/auth"
+)
+
+// defaultAuthPort is the local port the OAuth callback listens on, absent
+// --auth-port. It must match the redirect_uri registered in the Pocket
+// consumer key's settings, so it's fixed rather than an ephemeral port.
+const defaultAuthPort = 9736
+
+// authShutdownTimeout bounds how long waitForLocalCallback waits for the
+// callback server to shut down after the callback fires.
+const authShutdownTimeout = 5 * time.Second
+
+func computeConfigDir() string {
+	if xdg := os.Getenv("XDG_CONFIG_HOME"); xdg != "" {
+		return filepath.Join(xdg, "pocket")
+	}
+
+	usr, err := user.Current()
+	if err != nil {
+		panic(err)
+	}
+
+	return filepath.Join(usr.HomeDir, ".config", "pocket")
+}
+
+func getConsumerKey() string {
+	consumerKeyPath := filepath.Join(configDir, "consumer_key")
+	consumerKey, err := ioutil.ReadFile(consumerKeyPath)
+
+	if err != nil {
+		log.Printf("Can't get consumer key: %v", err)
+		log.Print("Enter your consumer key (from here https://getpocket.com/developer/apps/): ")
+
+		consumerKey, _, err = bufio.NewReader(os.Stdin).ReadLine()
+		if err != nil {
+			panic(err)
+		}
+
+		err = ioutil.WriteFile(consumerKeyPath, consumerKey, 0600)
+		if err != nil {
+			panic(err)
+		}
+
+		return string(consumerKey)
+	}
+
+	return string(bytes.SplitN(consumerKey, []byte("\n"), 2)[0])
+}
+
+func restoreAccessToken(consumerKey string, authPort int, headless bool) (*auth.Authorization, error) {
+	accessToken := &auth.Authorization{}
+	authFile := filepath.Join(configDir, "auth.json")
+
+	err := loadJSONFromFile(authFile, accessToken)
+
+	if err != nil {
+		log.Println(err)
+
+		accessToken, err = obtainAccessToken(consumerKey, authPort, headless)
+		if err != nil {
+			return nil, err
+		}
+
+		err = saveJSONToFile(authFile, accessToken)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return accessToken, nil
+}
+
+// obtainAccessToken runs the Pocket OAuth flow: it requests a request token
+// with a redirect_uri carrying a random state, sends the user to authorize
+// it, and waits for that state to come back before exchanging the request
+// token for an access token.
+func obtainAccessToken(consumerKey string, authPort int, headless bool) (*auth.Authorization, error) {
+	state, err := randomState()
+	if err != nil {
+		return nil, err
+	}
+
+	redirectURL := fmt.Sprintf("http://127.0.0.1:%d/callback?state=%s", authPort, state)
+
+	requestToken, err := auth.ObtainRequestToken(consumerKey, redirectURL)
+	if err != nil {
+		return nil, err
+	}
+
+	authURL := auth.GenerateAuthorizationURL(requestToken, redirectURL)
+
+	if headless {
+		if err := waitForHeadlessApproval(authURL, state); err != nil {
+			return nil, err
+		}
+	} else {
+		if err := waitForLocalCallback(authURL, state, authPort); err != nil {
+			return nil, err
+		}
+	}
+
+	return auth.ObtainAccessToken(consumerKey, requestToken)
+}
+
+func randomState() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// waitForLocalCallback starts a local HTTP server on authPort, prints authURL
+// for the user to open, and blocks until Pocket redirects back to it with
+// the matching state.
+func waitForLocalCallback(authURL, state string, authPort int) error {
+	addr := fmt.Sprintf("127.0.0.1:%d", authPort)
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("listening on %s: %w (pass a free --auth-port, or use --headless)", addr, err)
+	}
+
+	ch := make(chan error, 1)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/callback", func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Query().Get("state") != state {
+			http.Error(w, "state mismatch", http.StatusBadRequest)
+			ch <- errors.New("OAuth callback state mismatch; rejecting a possibly forged redirect")
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, authorizedHTML)
+		ch <- nil
+	})
+
+	server := &http.Server{Handler: mux}
+	go server.Serve(listener)
+
+	fmt.Println("Open this URL in a browser and authorize pocket-cli:")
+	fmt.Println(authURL)
+
+	err = <-ch
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), authShutdownTimeout)
+	defer cancel()
+	server.Shutdown(shutdownCtx)
+
+	return err
+}
+
+// waitForHeadlessApproval is used when a local callback server can't be
+// reached by a browser (e.g. on a remote host): it prints authURL and asks
+// the user to paste back the URL Pocket redirected them to, validating that
+// its state matches.
+func waitForHeadlessApproval(authURL, state string) error {
+	fmt.Println("Open this URL in a browser and authorize pocket-cli:")
+	fmt.Println(authURL)
+	fmt.Print("Paste the URL you were redirected to: ")
+
+	line, _, err := bufio.NewReader(os.Stdin).ReadLine()
+	if err != nil {
+		return err
+	}
+
+	redirected, err := url.Parse(strings.TrimSpace(string(line)))
+	if err != nil {
+		return fmt.Errorf("invalid URL: %v", err)
+	}
+
+	if redirected.Query().Get("state") != state {
+		return errors.New("the pasted URL's state doesn't match this authorization request")
+	}
+
+	return nil
+}
+
+const authorizedHTML = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>pocket-cli</title>
+<style>
+  body { font-family: -apple-system, BlinkMacSystemFont, sans-serif; background: #f4f4f4;
+         display: flex; align-items: center; justify-content: center; height: 100vh; margin: 0; }
+  .card { background: #fff; border-radius: 8px; padding: 2rem 3rem; box-shadow: 0 1px 4px rgba(0,0,0,0.15);
+          text-align: center; }
+  h1 { color: #2e7d32; margin-top: 0; }
+</style>
+</head>
+<body>
+<div class="card">
+  <h1>Authorized</h1>
+  <p>pocket-cli is now connected to your Pocket account. You can close this tab.</p>
+</div>
+</body>
+</html>
+`
+
+func saveJSONToFile(path string, v interface{}) error {
+	w, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	defer w.Close()
+
+	return json.NewEncoder(w).Encode(v)
+}
+
+func loadJSONFromFile(path string, v interface{}) error {
+	r, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+
+	defer r.Close()
+
+	return json.NewDecoder(r).Decode(v)
+}
@@ -0,0 +1,348 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/motemen/go-pocket/api"
+	"github.com/urfave/cli"
+)
+
+func commandBrowse(c *cli.Context) error {
+	client := c.App.Metadata["client"].(*api.Client)
+	consumerKey := c.App.Metadata["consumerKey"].(string)
+	accessToken := c.App.Metadata["accessToken"].(string)
+
+	items, err := fetchItems(client)
+	if err != nil {
+		return err
+	}
+
+	b, err := newBrowser(client, consumerKey, accessToken, items)
+	if err != nil {
+		return err
+	}
+	defer b.screen.Fini()
+
+	return b.run()
+}
+
+func fetchItems(client *api.Client) ([]api.Item, error) {
+	res, err := client.Retrieve(&api.RetrieveOption{DetailType: api.DetailTypeComplete})
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]api.Item, 0, len(res.List))
+	for _, item := range res.List {
+		items = append(items, item)
+	}
+
+	sort.Sort(bySortID(items))
+
+	return items, nil
+}
+
+type promptKind int
+
+const (
+	promptNone promptKind = iota
+	promptSearch
+	promptTag
+)
+
+// browser is a full-screen triage view over a retrieved item list. Actions
+// taken on an item (archive, tag, delete) are queued locally and only sent
+// to Pocket when flushed, either explicitly with `w` or on exit.
+type browser struct {
+	screen tcell.Screen
+
+	client      *api.Client
+	consumerKey string
+	accessToken string
+
+	items  []api.Item
+	cursor int
+
+	searchQuery string
+
+	promptMode  promptKind
+	promptInput string
+
+	pendingActions    []*api.Action
+	pendingTagActions []tagAction
+
+	status string
+}
+
+func newBrowser(client *api.Client, consumerKey, accessToken string, items []api.Item) (*browser, error) {
+	screen, err := tcell.NewScreen()
+	if err != nil {
+		return nil, err
+	}
+	if err := screen.Init(); err != nil {
+		return nil, err
+	}
+
+	return &browser{
+		screen:      screen,
+		client:      client,
+		consumerKey: consumerKey,
+		accessToken: accessToken,
+		items:       items,
+	}, nil
+}
+
+func (b *browser) run() error {
+	for {
+		b.draw()
+
+		switch ev := b.screen.PollEvent().(type) {
+		case *tcell.EventResize:
+			b.screen.Sync()
+		case *tcell.EventKey:
+			quit, err := b.handleKey(ev)
+			if err != nil {
+				b.status = err.Error()
+				continue
+			}
+			if quit {
+				return b.flush()
+			}
+		}
+	}
+}
+
+func (b *browser) handleKey(ev *tcell.EventKey) (bool, error) {
+	if b.promptMode != promptNone {
+		return false, b.handlePromptKey(ev)
+	}
+
+	switch ev.Key() {
+	case tcell.KeyEnter:
+		b.openCurrent()
+		return false, nil
+	case tcell.KeyDown:
+		b.move(1)
+		return false, nil
+	case tcell.KeyUp:
+		b.move(-1)
+		return false, nil
+	case tcell.KeyEscape:
+		b.searchQuery = ""
+		b.cursor = 0
+		return false, nil
+	}
+
+	switch ev.Rune() {
+	case 'q':
+		return true, nil
+	case 'j':
+		b.move(1)
+	case 'k':
+		b.move(-1)
+	case '/':
+		b.promptMode = promptSearch
+		b.promptInput = ""
+	case 'a':
+		b.queueSimple("archive")
+	case 'd':
+		b.queueSimple("delete")
+	case 't':
+		b.promptMode = promptTag
+		b.promptInput = ""
+	case 'r':
+		items, err := fetchItems(b.client)
+		if err != nil {
+			return false, err
+		}
+		b.items = items
+		b.cursor = 0
+		b.status = "refreshed from Pocket"
+	case 'w':
+		return false, b.flush()
+	}
+
+	return false, nil
+}
+
+func (b *browser) handlePromptKey(ev *tcell.EventKey) error {
+	switch ev.Key() {
+	case tcell.KeyEscape:
+		b.promptMode = promptNone
+		return nil
+	case tcell.KeyEnter:
+		mode, input := b.promptMode, b.promptInput
+		b.promptMode = promptNone
+		b.promptInput = ""
+
+		switch mode {
+		case promptSearch:
+			b.searchQuery = input
+			b.cursor = 0
+		case promptTag:
+			if input != "" {
+				b.queueTag(input)
+			}
+		}
+		return nil
+	case tcell.KeyBackspace, tcell.KeyBackspace2:
+		if len(b.promptInput) > 0 {
+			b.promptInput = b.promptInput[:len(b.promptInput)-1]
+		}
+		return nil
+	}
+
+	if r := ev.Rune(); r != 0 {
+		b.promptInput += string(r)
+	}
+
+	return nil
+}
+
+// visibleItems returns the items matching the active search query, or all
+// items when there is none.
+func (b *browser) visibleItems() []api.Item {
+	if b.searchQuery == "" {
+		return b.items
+	}
+
+	query := strings.ToLower(b.searchQuery)
+
+	var filtered []api.Item
+	for _, item := range b.items {
+		if strings.Contains(strings.ToLower(item.Title()), query) || strings.Contains(strings.ToLower(item.URL()), query) {
+			filtered = append(filtered, item)
+		}
+	}
+
+	return filtered
+}
+
+func (b *browser) move(delta int) {
+	items := b.visibleItems()
+	if len(items) == 0 {
+		return
+	}
+
+	b.cursor += delta
+	if b.cursor < 0 {
+		b.cursor = 0
+	}
+	if b.cursor >= len(items) {
+		b.cursor = len(items) - 1
+	}
+}
+
+func (b *browser) current() (api.Item, bool) {
+	items := b.visibleItems()
+	if b.cursor < 0 || b.cursor >= len(items) {
+		return api.Item{}, false
+	}
+	return items[b.cursor], true
+}
+
+func (b *browser) queueSimple(action string) {
+	item, ok := b.current()
+	if !ok {
+		return
+	}
+
+	b.pendingActions = append(b.pendingActions, &api.Action{Action: action, ItemID: item.ItemID})
+	b.status = fmt.Sprintf("queued %s for item %d", action, item.ItemID)
+}
+
+func (b *browser) queueTag(tags string) {
+	item, ok := b.current()
+	if !ok {
+		return
+	}
+
+	b.pendingTagActions = append(b.pendingTagActions, tagAction{Action: "tags_add", ItemID: item.ItemID, Tags: tags})
+	b.status = fmt.Sprintf("queued tag %q for item %d", tags, item.ItemID)
+}
+
+func (b *browser) openCurrent() {
+	item, ok := b.current()
+	if !ok {
+		return
+	}
+
+	browserCmd := os.Getenv("BROWSER")
+	if browserCmd == "" {
+		b.status = "$BROWSER is not set"
+		return
+	}
+
+	if err := exec.Command(browserCmd, item.URL()).Start(); err != nil {
+		b.status = err.Error()
+	}
+}
+
+// flush sends any queued actions to Pocket in batches and clears the queue.
+func (b *browser) flush() error {
+	if len(b.pendingActions) > 0 {
+		if err := submitActions(b.client, b.pendingActions, false); err != nil {
+			return err
+		}
+		b.pendingActions = nil
+	}
+
+	if len(b.pendingTagActions) > 0 {
+		if err := submitTagActions(b.consumerKey, b.accessToken, b.pendingTagActions, false); err != nil {
+			return err
+		}
+		b.pendingTagActions = nil
+	}
+
+	b.status = "synced"
+
+	return nil
+}
+
+func (b *browser) draw() {
+	b.screen.Clear()
+
+	width, height := b.screen.Size()
+
+	items := b.visibleItems()
+	for i, item := range items {
+		if i >= height-1 {
+			break
+		}
+
+		style := tcell.StyleDefault
+		if i == b.cursor {
+			style = style.Reverse(true)
+		}
+
+		line := fmt.Sprintf("[%9d] %s <%s>", item.ItemID, item.Title(), item.URL())
+		drawText(b.screen, 0, i, width, style, line)
+	}
+
+	statusLine := fmt.Sprintf("%d pending action(s) | %s", len(b.pendingActions)+len(b.pendingTagActions), b.status)
+	switch b.promptMode {
+	case promptSearch:
+		statusLine = "/" + b.promptInput
+	case promptTag:
+		statusLine = "tags: " + b.promptInput
+	}
+	drawText(b.screen, 0, height-1, width, tcell.StyleDefault.Reverse(true), statusLine)
+
+	b.screen.Show()
+}
+
+func drawText(screen tcell.Screen, x, y, maxWidth int, style tcell.Style, text string) {
+	col := x
+	for _, r := range text {
+		if col >= maxWidth {
+			break
+		}
+		screen.SetContent(col, y, r, nil, style)
+		col++
+	}
+}
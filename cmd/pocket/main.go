@@ -2,29 +2,35 @@ package main
 
 import (
 	"bufio"
-	"bytes"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
-	"log"
-	"net/http"
-	"net/http/httptest"
+	"io"
 	"os"
-	"os/user"
 	"path/filepath"
 	"sort"
 	"strconv"
+	"strings"
 	"text/template"
+	"time"
 
 	"errors"
 
 	"github.com/motemen/go-pocket/api"
-	"github.com/motemen/go-pocket/auth"
+	"github.com/mursts/pocket-cli/cache"
+	"github.com/mursts/pocket-cli/output"
 	"github.com/urfave/cli"
 )
 
 const (
 	version = "0.1"
+
+	// actionBatchSize is the maximum number of actions sent in a single
+	// /v3/send request, per Pocket's API limits.
+	actionBatchSize = 30
+
+	// cacheTTL is how long a synced cache is considered fresh enough to
+	// list from without hitting the network.
+	cacheTTL = time.Hour
 )
 
 var defaultItemTemplate = template.Must(template.New("item").Parse(
@@ -34,13 +40,9 @@ var defaultItemTemplate = template.Must(template.New("item").Parse(
 var configDir string
 
 func init() {
-	usr, err := user.Current()
-	if err != nil {
-		panic(err)
-	}
+	configDir = computeConfigDir()
 
-	configDir = filepath.Join(usr.HomeDir, ".config", "pocket")
-	err = os.MkdirAll(configDir, 0777)
+	err := os.MkdirAll(configDir, 0777)
 	if err != nil {
 		panic(err)
 	}
@@ -51,6 +53,17 @@ func main() {
 	app.Name = "pocket-cli"
 	app.Usage = "A Pocket command line client"
 	app.Version = version
+	app.Flags = []cli.Flag{
+		cli.IntFlag{
+			Name:  "auth-port",
+			Value: defaultAuthPort,
+			Usage: "Local port to listen on for the OAuth redirect during login.",
+		},
+		cli.BoolFlag{
+			Name:  "headless",
+			Usage: "During login, print the authorization URL and prompt for the redirected URL instead of listening locally.",
+		},
+	}
 
 	formatFlag := cli.StringFlag{
 		Name:  "format, f",
@@ -72,6 +85,15 @@ func main() {
 		Name:  "tag, t",
 		Usage: "Filter items by a tag when listing.",
 	}
+	refreshFlag := cli.BoolFlag{
+		Name:  "refresh, r",
+		Usage: "Sync the local cache from Pocket before listing.",
+	}
+	outputFlag := cli.StringFlag{
+		Name:  "output, o",
+		Usage: "Output format: template, json, tsv, opml.",
+		Value: "template",
+	}
 
 	titleFlag := cli.StringFlag{
 		Name:  "title, t",
@@ -81,11 +103,23 @@ func main() {
 		Name:  "tags, tg",
 		Usage: "A comma-separated list of tags",
 	}
+	dryRunFlag := cli.BoolFlag{
+		Name:  "dry-run",
+		Usage: "Print the actions payload instead of sending it.",
+	}
+	epubFlag := cli.StringFlag{
+		Name:  "epub",
+		Usage: "Write output to the given EPUB file instead of stdout.",
+	}
+	allUnreadFlag := cli.BoolFlag{
+		Name:  "all-unread",
+		Usage: "Fetch the whole cached unread queue instead of a single item.",
+	}
 
 	app.Before = func(c *cli.Context) error {
 		consumerKey := getConsumerKey()
 
-		accessToken, err := restoreAccessToken(consumerKey)
+		accessToken, err := restoreAccessToken(consumerKey, c.Int("auth-port"), c.Bool("headless"))
 		if err != nil {
 			panic(err)
 		}
@@ -93,7 +127,9 @@ func main() {
 		client := api.NewClient(consumerKey, accessToken.AccessToken)
 
 		app.Metadata = map[string]interface{}{
-			"client": client,
+			"client":      client,
+			"consumerKey": consumerKey,
+			"accessToken": accessToken.AccessToken,
 		}
 
 		return nil
@@ -111,6 +147,8 @@ func main() {
 				searchFlag,
 				countFlag,
 				tagFlag,
+				refreshFlag,
+				outputFlag,
 			},
 		},
 		{
@@ -125,8 +163,57 @@ func main() {
 		},
 		{
 			Name:   "archive",
-			Usage:  "Archive item",
+			Usage:  "Archive items",
 			Action: commandArchive,
+			Flags:  []cli.Flag{dryRunFlag},
+		},
+		{
+			Name:   "readd",
+			Usage:  "Re-add (unarchive) items",
+			Action: commandReadd,
+			Flags:  []cli.Flag{dryRunFlag},
+		},
+		{
+			Name:   "favorite",
+			Usage:  "Favorite items",
+			Action: commandFavorite,
+			Flags:  []cli.Flag{dryRunFlag},
+		},
+		{
+			Name:   "unfavorite",
+			Usage:  "Unfavorite items",
+			Action: commandUnfavorite,
+			Flags:  []cli.Flag{dryRunFlag},
+		},
+		{
+			Name:   "delete",
+			Usage:  "Delete items",
+			Action: commandDelete,
+			Flags:  []cli.Flag{dryRunFlag},
+		},
+		{
+			Name:   "tag",
+			Usage:  "Add tags to items",
+			Action: commandTag,
+			Flags:  []cli.Flag{tagsFlag, dryRunFlag},
+		},
+		{
+			Name:   "untag",
+			Usage:  "Remove tags from items",
+			Action: commandUntag,
+			Flags:  []cli.Flag{tagsFlag, dryRunFlag},
+		},
+		{
+			Name:    "browse",
+			Aliases: []string{"tui"},
+			Usage:   "Interactively browse and triage items",
+			Action:  commandBrowse,
+		},
+		{
+			Name:   "read",
+			Usage:  "Fetch an article in reader mode and export it as Markdown or EPUB",
+			Action: commandRead,
+			Flags:  []cli.Flag{epubFlag, allUnreadFlag},
 		},
 	}
 
@@ -143,200 +230,323 @@ func (s bySortID) Less(i, j int) bool { return s[i].SortId < s[j].SortId }
 func (s bySortID) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
 
 func commandList(c *cli.Context) error {
-	options := &api.RetrieveOption{}
-
-	if domain := c.String("domain"); domain != "" {
-		options.Domain = domain
+	store, err := cache.Open(filepath.Join(configDir, "cache.db"))
+	if err != nil {
+		return errors.New(fmt.Sprintf("failed to open cache. %v", err))
 	}
-	if search := c.String("search"); search != "" {
-		options.Search = search
+	defer store.Close()
+
+	client := c.App.Metadata["client"].(*api.Client)
+
+	if c.Bool("refresh") || cacheIsStale(store) {
+		if err := refreshCache(client, store); err != nil {
+			return errors.New(fmt.Sprintf("failed to refresh cache. %v", err))
+		}
 	}
-	if tag := c.String("tag"); tag != "" {
-		options.Tag = tag
+
+	options := cache.ListOptions{
+		Domain: c.String("domain"),
+		Tag:    c.String("tag"),
+		Search: c.String("search"),
+		Count:  10,
 	}
-	options.Count = 10
 	if count := c.String("count"); count != "" {
 		if i, err := strconv.Atoi(count); err == nil {
 			options.Count = i
 		}
 	}
 
-	client := c.App.Metadata["client"].(*api.Client)
-
-	res, err := client.Retrieve(options)
+	items, err := store.List(options)
 	if err != nil {
-		return errors.New(fmt.Sprintf("failed to item retrieve. %v", err))
-	}
-
-	var itemTemplate *template.Template
-	if format := c.String("format"); format != "" {
-		itemTemplate = template.Must(template.New("item").Parse(format))
-	} else {
-		itemTemplate = defaultItemTemplate
-	}
-
-	var items []api.Item
-	for _, item := range res.List {
-		items = append(items, item)
+		return errors.New(fmt.Sprintf("failed to list cached items. %v", err))
 	}
 
 	sort.Sort(bySortID(items))
 
-	for _, item := range items {
-		err := itemTemplate.Execute(os.Stdout, item)
-		if err != nil {
-			panic(err)
+	switch out := c.String("output"); out {
+	case "", "template":
+		var itemTemplate *template.Template
+		if format := c.String("format"); format != "" {
+			itemTemplate = template.Must(template.New("item").Parse(format))
+		} else {
+			itemTemplate = defaultItemTemplate
+		}
+
+		for _, item := range items {
+			err := itemTemplate.Execute(os.Stdout, item)
+			if err != nil {
+				panic(err)
+			}
+			fmt.Println("")
 		}
-		fmt.Println("")
+	case "json":
+		return output.JSON(os.Stdout, items)
+	case "tsv":
+		return output.TSV(os.Stdout, items)
+	case "opml":
+		return output.OPML(os.Stdout, items)
+	default:
+		return fmt.Errorf("unknown output format %q", out)
 	}
 
 	return nil
 }
 
-func commandArchive(c *cli.Context) error {
-	itemIDString := c.Args().First()
-	if itemIDString == "" {
-		return errors.New("item id not found")
+// cacheIsStale reports whether the cache has never been synced, or was last
+// synced longer ago than cacheTTL.
+func cacheIsStale(store *cache.Store) bool {
+	syncedAt, err := store.SyncedAt()
+	if err != nil || syncedAt.IsZero() {
+		return true
 	}
+	return time.Since(syncedAt) > cacheTTL
+}
 
-	itemID, err := strconv.Atoi(itemIDString)
+// refreshCache retrieves the delta since the cache's last sync and merges it
+// in. It requests api.StateAll so that items archived or deleted since the
+// last sync are reported and can be dropped from the cache.
+func refreshCache(client *api.Client, store *cache.Store) error {
+	since, err := store.Since()
 	if err != nil {
-		return errors.New("item id should be number")
+		return err
 	}
 
-	client := c.App.Metadata["client"].(*api.Client)
-
-	action := api.NewArchiveAction(itemID)
-	res, err := client.Modify(action)
-	fmt.Println(res, err)
+	res, err := client.Retrieve(&api.RetrieveOption{
+		State:      api.StateAll,
+		DetailType: api.DetailTypeComplete,
+		Since:      since,
+	})
+	if err != nil {
+		return err
+	}
 
-	return nil
+	return store.Merge(res, time.Now())
 }
 
-func commandAdd(c *cli.Context) error {
-	options := &api.AddOption{}
-
-	url := c.Args().First()
-	if url == "" {
-		return errors.New("url not found")
+// collectItemIDs reads item IDs from the command's positional arguments, or
+// from stdin (one per line) when the sole argument is "-". This lets actions
+// be chained off `list`, e.g. `pocket-cli list -t stale -f '{{.ItemID}}' |
+// pocket-cli archive -`.
+func collectItemIDs(c *cli.Context) ([]int, error) {
+	args := c.Args()
+	if len(args) == 1 && args[0] == "-" {
+		return readItemIDs(os.Stdin)
 	}
 
-	options.URL = url
-
-	if title := c.String("title"); title != "" {
-		options.Title = title
+	if len(args) == 0 {
+		return nil, errors.New("item id not found")
 	}
 
-	if tags := c.String("--tags"); tags != "" {
-		options.Tags = tags
+	itemIDs := make([]int, len(args))
+	for i, arg := range args {
+		itemID, err := strconv.Atoi(arg)
+		if err != nil {
+			return nil, errors.New("item id should be number")
+		}
+		itemIDs[i] = itemID
 	}
 
-	client := c.App.Metadata["client"].(*api.Client)
+	return itemIDs, nil
+}
 
-	err := client.Add(options)
-	if err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+func readItemIDs(r io.Reader) ([]int, error) {
+	var itemIDs []int
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		itemID, err := strconv.Atoi(line)
+		if err != nil {
+			return nil, errors.New("item id should be number")
+		}
+		itemIDs = append(itemIDs, itemID)
 	}
 
-	return nil
-}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
 
-func getConsumerKey() string {
-	consumerKeyPath := filepath.Join(configDir, "consumer_key")
-	consumerKey, err := ioutil.ReadFile(consumerKeyPath)
+	return itemIDs, nil
+}
 
-	if err != nil {
-		log.Printf("Can't get consumer key: %v", err)
-		log.Print("Enter your consumer key (from here https://getpocket.com/developer/apps/): ")
+// submitActions sends actions to the Modify endpoint in batches of
+// actionBatchSize, since Pocket's /v3/send endpoint caps the number of
+// actions accepted per request. With dryRun, it prints the JSON payload for
+// each batch instead of sending it.
+func submitActions(client *api.Client, actions []*api.Action, dryRun bool) error {
+	for start := 0; start < len(actions); start += actionBatchSize {
+		end := start + actionBatchSize
+		if end > len(actions) {
+			end = len(actions)
+		}
+		batch := actions[start:end]
 
-		consumerKey, _, err = bufio.NewReader(os.Stdin).ReadLine()
-		if err != nil {
-			panic(err)
+		if dryRun {
+			b, err := json.MarshalIndent(batch, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(b))
+			continue
 		}
 
-		err = ioutil.WriteFile(consumerKeyPath, consumerKey, 0600)
+		res, err := client.Modify(batch...)
 		if err != nil {
-			panic(err)
+			return err
 		}
+		fmt.Println(res)
+	}
+
+	return nil
+}
+
+// runBulkAction builds one api.Action per item ID with the given action name
+// and submits them through submitActions.
+func runBulkAction(c *cli.Context, action string) error {
+	itemIDs, err := collectItemIDs(c)
+	if err != nil {
+		return err
+	}
 
-		return string(consumerKey)
+	actions := make([]*api.Action, len(itemIDs))
+	for i, itemID := range itemIDs {
+		actions[i] = &api.Action{Action: action, ItemID: itemID}
 	}
 
-	return string(bytes.SplitN(consumerKey, []byte("\n"), 2)[0])
+	client := c.App.Metadata["client"].(*api.Client)
+
+	return submitActions(client, actions, c.Bool("dry-run"))
 }
 
-func restoreAccessToken(consumerKey string) (*auth.Authorization, error) {
-	accessToken := &auth.Authorization{}
-	authFile := filepath.Join(configDir, "auth.json")
+func commandArchive(c *cli.Context) error {
+	return runBulkAction(c, "archive")
+}
 
-	err := loadJSONFromFile(authFile, accessToken)
+func commandReadd(c *cli.Context) error {
+	return runBulkAction(c, "readd")
+}
 
-	if err != nil {
-		log.Println(err)
+func commandFavorite(c *cli.Context) error {
+	return runBulkAction(c, "favorite")
+}
 
-		accessToken, err = obtainAccessToken(consumerKey)
-		if err != nil {
-			return nil, err
-		}
+func commandUnfavorite(c *cli.Context) error {
+	return runBulkAction(c, "unfavorite")
+}
 
-		err = saveJSONToFile(authFile, accessToken)
-		if err != nil {
-			return nil, err
-		}
-	}
+func commandDelete(c *cli.Context) error {
+	return runBulkAction(c, "delete")
+}
 
-	return accessToken, nil
+// tagAction mirrors api.Action but additionally carries the comma-separated
+// tags list that Pocket's tags_add/tags_remove actions require. go-pocket's
+// api.Action has no such field, so tag actions are sent through api.PostJSON
+// directly rather than client.Modify.
+type tagAction struct {
+	Action string `json:"action"`
+	ItemID int    `json:"item_id,string"`
+	Tags   string `json:"tags"`
 }
 
-func obtainAccessToken(consumerKey string) (*auth.Authorization, error) {
-	ch := make(chan struct{})
-	ts := httptest.NewServer(
-		http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-			if req.URL.Path == "/favicon.ico" {
-				http.Error(w, "Not Found", 404)
-				return
-			}
+type tagModifyOption struct {
+	Actions []tagAction `json:"actions"`
 
-			w.Header().Set("Content-Type", "text/plain")
-			fmt.Fprintln(w, "Authorized.")
-			ch <- struct{}{}
-		}))
-	defer ts.Close()
+	ConsumerKey string `json:"consumer_key"`
+	AccessToken string `json:"access_token"`
+}
 
-	redirectURL := ts.URL
+func submitTagActions(consumerKey, accessToken string, actions []tagAction, dryRun bool) error {
+	for start := 0; start < len(actions); start += actionBatchSize {
+		end := start + actionBatchSize
+		if end > len(actions) {
+			end = len(actions)
+		}
+		batch := actions[start:end]
 
-	requestToken, err := auth.ObtainRequestToken(consumerKey, redirectURL)
-	if err != nil {
-		return nil, err
-	}
+		if dryRun {
+			b, err := json.MarshalIndent(batch, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(b))
+			continue
+		}
 
-	url := auth.GenerateAuthorizationURL(requestToken, redirectURL)
-	fmt.Println(url)
+		data := tagModifyOption{
+			Actions:     batch,
+			ConsumerKey: consumerKey,
+			AccessToken: accessToken,
+		}
 
-	<-ch
+		res := &api.ModifyResult{}
+		if err := api.PostJSON("/v3/send", data, res); err != nil {
+			return err
+		}
+		fmt.Println(res)
+	}
 
-	return auth.ObtainAccessToken(consumerKey, requestToken)
+	return nil
 }
 
-func saveJSONToFile(path string, v interface{}) error {
-	w, err := os.Create(path)
+func runBulkTagAction(c *cli.Context, action string) error {
+	tags := c.String("tags")
+	if tags == "" {
+		return errors.New("--tags is required")
+	}
+
+	itemIDs, err := collectItemIDs(c)
 	if err != nil {
 		return err
 	}
 
-	defer w.Close()
+	actions := make([]tagAction, len(itemIDs))
+	for i, itemID := range itemIDs {
+		actions[i] = tagAction{Action: action, ItemID: itemID, Tags: tags}
+	}
+
+	consumerKey := c.App.Metadata["consumerKey"].(string)
+	accessToken := c.App.Metadata["accessToken"].(string)
 
-	return json.NewEncoder(w).Encode(v)
+	return submitTagActions(consumerKey, accessToken, actions, c.Bool("dry-run"))
 }
 
-func loadJSONFromFile(path string, v interface{}) error {
-	r, err := os.Open(path)
-	if err != nil {
-		return err
+func commandTag(c *cli.Context) error {
+	return runBulkTagAction(c, "tags_add")
+}
+
+func commandUntag(c *cli.Context) error {
+	return runBulkTagAction(c, "tags_remove")
+}
+
+func commandAdd(c *cli.Context) error {
+	options := &api.AddOption{}
+
+	url := c.Args().First()
+	if url == "" {
+		return errors.New("url not found")
+	}
+
+	options.URL = url
+
+	if title := c.String("title"); title != "" {
+		options.Title = title
+	}
+
+	if tags := c.String("--tags"); tags != "" {
+		options.Tags = tags
 	}
 
-	defer r.Close()
+	client := c.App.Metadata["client"].(*api.Client)
+
+	err := client.Add(options)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 
-	return json.NewDecoder(r).Decode(v)
+	return nil
 }
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/motemen/go-pocket/api"
+	"github.com/mursts/pocket-cli/cache"
+	"github.com/mursts/pocket-cli/epub"
+	"github.com/mursts/pocket-cli/reader"
+	"github.com/urfave/cli"
+)
+
+// articleFetchTimeout bounds each article download so a single slow or
+// hanging server can't wedge a batch export (e.g. --all-unread --epub).
+const articleFetchTimeout = 30 * time.Second
+
+var articleHTTPClient = &http.Client{Timeout: articleFetchTimeout}
+
+func commandRead(c *cli.Context) error {
+	client := c.App.Metadata["client"].(*api.Client)
+
+	store, err := cache.Open(filepath.Join(configDir, "cache.db"))
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	if cacheIsStale(store) {
+		if err := refreshCache(client, store); err != nil {
+			return err
+		}
+	}
+
+	items, err := readTargets(c, store)
+	if err != nil {
+		return err
+	}
+
+	var chapters []epub.Chapter
+	epubPath := c.String("epub")
+
+	for _, item := range items {
+		article, err := fetchArticle(item)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "skipping %s: %v\n", item.URL(), err)
+			continue
+		}
+
+		if epubPath == "" {
+			fmt.Printf("# %s\n\n%s\n\n", article.Title, article.Markdown)
+			continue
+		}
+
+		chapters = append(chapters, epub.Chapter{Title: article.Title, URL: item.URL(), Markdown: article.Markdown})
+	}
+
+	if epubPath == "" {
+		return nil
+	}
+
+	title := "Pocket articles"
+	if len(chapters) == 1 {
+		title = chapters[0].Title
+	}
+
+	f, err := os.Create(epubPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return epub.Write(f, title, chapters)
+}
+
+// readTargets resolves the items `read` should fetch: either the single
+// item given on the command line, or the whole cached unread queue with
+// --all-unread.
+func readTargets(c *cli.Context, store *cache.Store) ([]api.Item, error) {
+	if c.Bool("all-unread") {
+		return store.List(cache.ListOptions{})
+	}
+
+	itemIDString := c.Args().First()
+	if itemIDString == "" {
+		return nil, errors.New("item id not found")
+	}
+
+	itemID, err := strconv.Atoi(itemIDString)
+	if err != nil {
+		return nil, errors.New("item id should be number")
+	}
+
+	item, ok, err := store.Get(itemID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("item %d not found; try `pocket-cli list --refresh` first", itemID)
+	}
+
+	return []api.Item{item}, nil
+}
+
+// fetchArticle fetches item's URL (or reuses a previously cached copy under
+// the "articles" directory in configDir) and extracts its readable content.
+func fetchArticle(item api.Item) (*reader.Article, error) {
+	articlesDir := filepath.Join(configDir, "articles")
+	if err := os.MkdirAll(articlesDir, 0777); err != nil {
+		return nil, err
+	}
+
+	cachePath := filepath.Join(articlesDir, strconv.Itoa(item.ItemID)+".html")
+
+	data, err := ioutil.ReadFile(cachePath)
+	if err != nil {
+		data, err = reader.Download(articleHTTPClient, item.URL())
+		if err != nil {
+			return nil, err
+		}
+
+		if err := ioutil.WriteFile(cachePath, data, 0600); err != nil {
+			return nil, err
+		}
+	}
+
+	article, err := reader.Parse(bytes.NewReader(data), item.URL())
+	if err != nil {
+		return nil, err
+	}
+	if article.Title == "" {
+		article.Title = item.Title()
+	}
+
+	return article, nil
+}
@@ -0,0 +1,187 @@
+// Package epub assembles a minimal, valid EPUB 2 document from a list of
+// chapters, for offline reading on e-readers.
+package epub
+
+import (
+	"archive/zip"
+	"fmt"
+	"hash/fnv"
+	"html"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// Chapter is one article bundled into an EPUB.
+type Chapter struct {
+	Title string
+	URL   string
+	// Markdown is the chapter body. Only paragraphs, headings (#.. ######),
+	// and list items (- ...) are rendered; this mirrors what package reader
+	// produces.
+	Markdown string
+}
+
+const containerXML = `<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>
+`
+
+const contentOPFTemplate = `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="2.0" unique-identifier="BookId">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:title>%s</dc:title>
+    <dc:language>en</dc:language>
+    <dc:identifier id="BookId">pocket-cli-%s</dc:identifier>
+  </metadata>
+  <manifest>
+    <item id="ncx" href="toc.ncx" media-type="application/x-dtbncx+xml"/>
+%s  </manifest>
+  <spine toc="ncx">
+%s  </spine>
+</package>
+`
+
+const tocNCXTemplate = `<?xml version="1.0" encoding="UTF-8"?>
+<ncx xmlns="http://www.daisy.org/z3986/2005/ncx/" version="2005-1">
+  <head>
+    <meta name="dtb:uid" content="pocket-cli-%s"/>
+  </head>
+  <docTitle><text>%s</text></docTitle>
+  <navMap>
+%s  </navMap>
+</ncx>
+`
+
+// Write assembles an EPUB titled title, containing chapters in order, and
+// writes it to w.
+func Write(w io.Writer, title string, chapters []Chapter) error {
+	zw := zip.NewWriter(w)
+
+	// The mimetype entry must be first and stored uncompressed, per the
+	// EPUB OCF spec.
+	mimetypeHeader := &zip.FileHeader{Name: "mimetype", Method: zip.Store}
+	mw, err := zw.CreateHeader(mimetypeHeader)
+	if err != nil {
+		return err
+	}
+	if _, err := mw.Write([]byte("application/epub+zip")); err != nil {
+		return err
+	}
+
+	if err := writeFile(zw, "META-INF/container.xml", containerXML); err != nil {
+		return err
+	}
+
+	var manifestItems, spineItems, navPoints strings.Builder
+	for i, ch := range chapters {
+		id := fmt.Sprintf("chapter%03d", i+1)
+		file := id + ".xhtml"
+
+		fmt.Fprintf(&manifestItems, `    <item id="%s" href="%s" media-type="application/xhtml+xml"/>`+"\n", id, file)
+		fmt.Fprintf(&spineItems, `    <itemref idref="%s"/>`+"\n", id)
+		fmt.Fprintf(&navPoints, `    <navPoint id="%s" playOrder="%d"><navLabel><text>%s</text></navLabel><content src="%s"/></navPoint>`+"\n",
+			id, i+1, html.EscapeString(ch.Title), file)
+
+		if err := writeFile(zw, "OEBPS/"+file, chapterXHTML(ch)); err != nil {
+			return err
+		}
+	}
+
+	opf := fmt.Sprintf(contentOPFTemplate, html.EscapeString(title), bookID(title), manifestItems.String(), spineItems.String())
+	if err := writeFile(zw, "OEBPS/content.opf", opf); err != nil {
+		return err
+	}
+
+	ncx := fmt.Sprintf(tocNCXTemplate, bookID(title), html.EscapeString(title), navPoints.String())
+	if err := writeFile(zw, "OEBPS/toc.ncx", ncx); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+func writeFile(zw *zip.Writer, name, contents string) error {
+	w, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, contents)
+	return err
+}
+
+// markdownLink matches the only inline markup package reader emits:
+// [text](href) links. Other Markdown emphasis (bold/italic) isn't produced
+// by reader, so there's nothing else to handle here yet.
+var markdownLink = regexp.MustCompile(`\[([^\]]*)\]\(([^)]*)\)`)
+
+// renderInline HTML-escapes text and turns any [text](href) links in it into
+// <a href> tags, so links survive the Markdown-to-XHTML conversion instead
+// of showing up to the reader as literal bracket-and-paren text.
+func renderInline(text string) string {
+	var out strings.Builder
+	last := 0
+	for _, loc := range markdownLink.FindAllStringSubmatchIndex(text, -1) {
+		out.WriteString(html.EscapeString(text[last:loc[0]]))
+		linkText, href := text[loc[2]:loc[3]], text[loc[4]:loc[5]]
+		fmt.Fprintf(&out, `<a href="%s">%s</a>`, html.EscapeString(href), html.EscapeString(linkText))
+		last = loc[1]
+	}
+	out.WriteString(html.EscapeString(text[last:]))
+	return out.String()
+}
+
+// bookID derives a stable, XML-safe identifier from title. It is a hash
+// rather than a sanitized form of title itself, so it never needs escaping
+// wherever it's interpolated into XML.
+func bookID(title string) string {
+	h := fnv.New32a()
+	io.WriteString(h, title)
+	return fmt.Sprintf("%08x", h.Sum32())
+}
+
+func chapterXHTML(ch Chapter) string {
+	var body strings.Builder
+	for _, block := range strings.Split(ch.Markdown, "\n\n") {
+		block = strings.TrimSpace(block)
+		if block == "" {
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(block, "###### "):
+			fmt.Fprintf(&body, "<h6>%s</h6>\n", renderInline(strings.TrimPrefix(block, "###### ")))
+		case strings.HasPrefix(block, "##### "):
+			fmt.Fprintf(&body, "<h5>%s</h5>\n", renderInline(strings.TrimPrefix(block, "##### ")))
+		case strings.HasPrefix(block, "#### "):
+			fmt.Fprintf(&body, "<h4>%s</h4>\n", renderInline(strings.TrimPrefix(block, "#### ")))
+		case strings.HasPrefix(block, "### "):
+			fmt.Fprintf(&body, "<h3>%s</h3>\n", renderInline(strings.TrimPrefix(block, "### ")))
+		case strings.HasPrefix(block, "## "):
+			fmt.Fprintf(&body, "<h2>%s</h2>\n", renderInline(strings.TrimPrefix(block, "## ")))
+		case strings.HasPrefix(block, "# "):
+			fmt.Fprintf(&body, "<h1>%s</h1>\n", renderInline(strings.TrimPrefix(block, "# ")))
+		case strings.HasPrefix(block, "- "):
+			fmt.Fprintf(&body, "<ul>\n")
+			for _, line := range strings.Split(block, "\n") {
+				fmt.Fprintf(&body, "<li>%s</li>\n", renderInline(strings.TrimPrefix(strings.TrimSpace(line), "- ")))
+			}
+			fmt.Fprintf(&body, "</ul>\n")
+		default:
+			fmt.Fprintf(&body, "<p>%s</p>\n", renderInline(block))
+		}
+	}
+
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml">
+<head><title>%s</title></head>
+<body>
+<h1>%s</h1>
+%s</body>
+</html>
+`, html.EscapeString(ch.Title), html.EscapeString(ch.Title), body.String())
+}
@@ -0,0 +1,62 @@
+package epub
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestWriteRendersInlineLinks(t *testing.T) {
+	chapters := []Chapter{
+		{
+			Title:    "Test Chapter",
+			URL:      "https://example.com/test",
+			Markdown: "Check this [cool site](https://example.com) out.",
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, "Test Book", chapters); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	xhtml := readZipFile(t, buf.Bytes(), "OEBPS/chapter001.xhtml")
+
+	if strings.Contains(xhtml, "[cool site]") {
+		t.Errorf("chapter XHTML = %q, want the Markdown link syntax converted, not left literal", xhtml)
+	}
+	if !strings.Contains(xhtml, `<a href="https://example.com">cool site</a>`) {
+		t.Errorf("chapter XHTML = %q, want an <a href> for the Markdown link", xhtml)
+	}
+}
+
+func readZipFile(t *testing.T, zipData []byte, name string) string {
+	t.Helper()
+
+	zr, err := zip.NewReader(bytes.NewReader(zipData), int64(len(zipData)))
+	if err != nil {
+		t.Fatalf("opening zip: %v", err)
+	}
+
+	for _, f := range zr.File {
+		if f.Name != name {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("opening %s: %v", name, err)
+		}
+		defer rc.Close()
+
+		data, err := io.ReadAll(rc)
+		if err != nil {
+			t.Fatalf("reading %s: %v", name, err)
+		}
+		return string(data)
+	}
+
+	t.Fatalf("zip has no file %s", name)
+	return ""
+}
@@ -0,0 +1,161 @@
+// Package output renders Pocket items in formats other than the default Go
+// template, for piping into other tools.
+package output
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/motemen/go-pocket/api"
+)
+
+// tsvColumns is the stable column set emitted by TSV.
+var tsvColumns = []string{"item_id", "title", "url", "domain", "tags", "time_added", "word_count"}
+
+// JSON writes items as a pretty-printed JSON array.
+func JSON(w io.Writer, items []api.Item) error {
+	if items == nil {
+		items = []api.Item{}
+	}
+
+	b, err := json.MarshalIndent(items, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(w, string(b))
+	return err
+}
+
+// TSV writes items as tab-separated values with a header row.
+func TSV(w io.Writer, items []api.Item) error {
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintln(bw, strings.Join(tsvColumns, "\t"))
+
+	for _, item := range items {
+		row := []string{
+			strconv.Itoa(item.ItemID),
+			tsvSafe(item.Title()),
+			tsvSafe(item.URL()),
+			tsvSafe(domainOf(item.URL())),
+			tsvSafe(strings.Join(tagNames(item.Tags), ",")),
+			strconv.FormatInt(time.Time(item.TimeAdded).Unix(), 10),
+			strconv.Itoa(item.WordCount),
+		}
+		fmt.Fprintln(bw, strings.Join(row, "\t"))
+	}
+
+	return bw.Flush()
+}
+
+// tsvReplacer strips characters that would otherwise corrupt TSV's row/field
+// structure (a literal tab or newline in a Pocket title is common).
+var tsvReplacer = strings.NewReplacer("\t", " ", "\n", " ", "\r", " ")
+
+func tsvSafe(s string) string {
+	return tsvReplacer.Replace(s)
+}
+
+type opmlDocument struct {
+	XMLName xml.Name `xml:"opml"`
+	Version string   `xml:"version,attr"`
+	Head    opmlHead `xml:"head"`
+	Body    opmlBody `xml:"body"`
+}
+
+type opmlHead struct {
+	Title string `xml:"title"`
+}
+
+type opmlBody struct {
+	Outlines []opmlOutline `xml:"outline"`
+}
+
+type opmlOutline struct {
+	Text     string        `xml:"text,attr"`
+	Type     string        `xml:"type,attr,omitempty"`
+	HTMLURL  string        `xml:"htmlUrl,attr,omitempty"`
+	Outlines []opmlOutline `xml:"outline,omitempty"`
+}
+
+// untaggedGroup is the OPML group name used for items with no tags.
+const untaggedGroup = "untagged"
+
+// OPML writes items as an OPML 2.0 document, one outline per item, grouped
+// by tag, so the list can be imported into a feed reader.
+func OPML(w io.Writer, items []api.Item) error {
+	var groupOrder []string
+	groups := map[string][]api.Item{}
+
+	for _, item := range items {
+		tags := tagNames(item.Tags)
+		if len(tags) == 0 {
+			tags = []string{untaggedGroup}
+		}
+
+		for _, tag := range tags {
+			if _, ok := groups[tag]; !ok {
+				groupOrder = append(groupOrder, tag)
+			}
+			groups[tag] = append(groups[tag], item)
+		}
+	}
+
+	sort.Strings(groupOrder)
+
+	doc := opmlDocument{
+		Version: "2.0",
+		Head:    opmlHead{Title: "Pocket items"},
+	}
+
+	for _, tag := range groupOrder {
+		group := opmlOutline{Text: tag}
+		for _, item := range groups[tag] {
+			group.Outlines = append(group.Outlines, opmlOutline{
+				Text:    item.Title(),
+				Type:    "link",
+				HTMLURL: item.URL(),
+			})
+		}
+		doc.Body.Outlines = append(doc.Body.Outlines, group)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func domainOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+func tagNames(tags map[string]map[string]interface{}) []string {
+	names := make([]string, 0, len(tags))
+	for name := range tags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
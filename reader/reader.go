@@ -0,0 +1,347 @@
+// Package reader extracts readable article content from a fetched web page
+// using a density-scoring heuristic similar to Readability-style extractors:
+// paragraphs are scored by text/comma density, that score is propagated up to
+// their ancestor containers, containers are biased by class/id hints (e.g.
+// "content" vs. "sidebar"/"comments") and penalized for high link density,
+// and the highest-scoring container is rendered as the article body instead
+// of the whole page. This is a heuristic, not a full port of a maintained
+// readability library, so pathological layouts can still fool it — but
+// unlike a flat tag-stripper it correctly drops sidebars, comment threads,
+// and related-post lists that sites place in plain div/ul elements rather
+// than semantic nav/aside/footer tags.
+package reader
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Article is the result of extracting readable content from a URL.
+type Article struct {
+	Title    string
+	URL      string
+	Markdown string
+}
+
+// skipTags are elements whose content is never part of the article body,
+// regardless of where they sit in the DOM.
+var skipTags = map[string]bool{
+	"script": true, "style": true, "nav": true, "header": true, "footer": true,
+	"aside": true, "noscript": true, "iframe": true, "form": true, "svg": true,
+}
+
+// minParagraphLen is the shortest paragraph text that counts as a scoring
+// signal; shorter snippets are usually captions or UI labels, not prose.
+const minParagraphLen = 25
+
+// unlikelyClassID matches class/id tokens that mark boilerplate: comment
+// threads, navigation, ads, social/share bars, cookie banners, and the like.
+var unlikelyClassID = regexp.MustCompile(`(?i)comment|combx|community|disqus|extra|foot|header|menu|nav|related|remark|rss|shoutbox|sidebar|skyscraper|sponsor|ad-break|agile|pager|popup|tweet|twitter|banner|cookie|consent|subscribe|newsletter|widget|social|share|masthead|promo`)
+
+// likelyClassID matches class/id tokens that mark the main article body. In
+// isBoilerplate it takes priority over unlikelyClassID, so containers like
+// "article-related-topics" (which technically match both) are kept; in
+// classWeight the two cancel out.
+var likelyClassID = regexp.MustCompile(`(?i)article|body|content|entry|hentry|main|page|post|text|blog|story`)
+
+var blankLines = regexp.MustCompile(`\n{3,}`)
+
+// Fetch downloads rawURL and extracts its readable content as Markdown.
+func Fetch(httpClient *http.Client, rawURL string) (*Article, error) {
+	data, err := Download(httpClient, rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return Parse(bytes.NewReader(data), rawURL)
+}
+
+// Download fetches rawURL's raw response body using httpClient, so callers
+// that need to cache the HTML alongside the extracted article (as `read`
+// does) don't have to reimplement the request/status-check logic themselves.
+func Download(httpClient *http.Client, rawURL string) ([]byte, error) {
+	resp, err := httpClient.Get(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s: got status %d", rawURL, resp.StatusCode)
+	}
+
+	return io.ReadAll(resp.Body)
+}
+
+// Parse extracts readable content as Markdown from r, an HTML document.
+func Parse(r io.Reader, rawURL string) (*Article, error) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf strings.Builder
+	if content := findMainContent(doc); content != nil {
+		renderChildren(&buf, content)
+	}
+
+	markdown := blankLines.ReplaceAllString(strings.TrimSpace(buf.String()), "\n\n")
+
+	return &Article{
+		Title:    findTitle(doc),
+		URL:      rawURL,
+		Markdown: markdown,
+	}, nil
+}
+
+// findMainContent scores every element that could plausibly contain the
+// article body and returns the highest-scoring one, falling back to <body>
+// if nothing scored above zero (e.g. a page with no real paragraphs).
+func findMainContent(doc *html.Node) *html.Node {
+	scores := scoreCandidates(doc)
+
+	var best *html.Node
+	var bestScore float64
+	for n, score := range scores {
+		adjusted := score * (1 - linkDensity(n))
+		if best == nil || adjusted > bestScore {
+			best, bestScore = n, adjusted
+		}
+	}
+
+	if best == nil || bestScore <= 0 {
+		return findNode(doc, "body")
+	}
+	return best
+}
+
+// scoreCandidates walks doc looking for paragraph-like text (p/pre/td/
+// blockquote) and attributes its score to the element's parent, grandparent,
+// and great-grandparent, discounted the further up it propagates. This
+// mirrors how Readability-style extractors locate the container that holds
+// most of the article's prose without needing to classify every tag.
+func scoreCandidates(n *html.Node) map[*html.Node]float64 {
+	scores := map[*html.Node]float64{}
+
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch n.Data {
+			case "p", "pre", "td", "blockquote":
+				scoreParagraph(n, scores)
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+
+	return scores
+}
+
+func scoreParagraph(n *html.Node, scores map[*html.Node]float64) {
+	text := strings.TrimSpace(textContent(n))
+	if len(text) < minParagraphLen {
+		return
+	}
+
+	contentScore := 1.0 + float64(strings.Count(text, ","))
+	if bonus := len(text) / 100; bonus > 0 {
+		if bonus > 3 {
+			bonus = 3
+		}
+		contentScore += float64(bonus)
+	}
+
+	divisor := 1.0
+	for ancestor := n.Parent; ancestor != nil && divisor <= 3; ancestor, divisor = ancestor.Parent, divisor+1 {
+		if _, seen := scores[ancestor]; !seen {
+			scores[ancestor] = tagWeight(ancestor.Data) + classWeight(ancestor)
+		}
+		scores[ancestor] += contentScore / divisor
+	}
+}
+
+// tagWeight is the prior belief a container holds real content, before
+// looking at any text inside it: divs (and, less so, pre/td/blockquote) are
+// common article wrappers, list/heading elements and th rarely are.
+func tagWeight(tag string) float64 {
+	switch tag {
+	case "div":
+		return 5
+	case "pre", "td", "blockquote":
+		return 3
+	case "address", "ol", "ul", "dl", "dd", "dt", "li", "form":
+		return -3
+	case "h1", "h2", "h3", "h4", "h5", "h6", "th":
+		return -5
+	default:
+		return 0
+	}
+}
+
+// classWeight biases a container by its class/id, e.g. "article-body" vs.
+// "sidebar-widget".
+func classWeight(n *html.Node) float64 {
+	hint := attr(n, "class") + " " + attr(n, "id")
+	if strings.TrimSpace(hint) == "" {
+		return 0
+	}
+
+	var weight float64
+	if likelyClassID.MatchString(hint) {
+		weight += 25
+	}
+	if unlikelyClassID.MatchString(hint) {
+		weight -= 25
+	}
+	return weight
+}
+
+// linkDensity is the fraction of n's text that sits inside <a> elements.
+// Navigation blocks and "related articles" lists score highly on raw text
+// length but are almost entirely links, so this is what tells them apart
+// from genuine prose.
+func linkDensity(n *html.Node) float64 {
+	total := len(strings.TrimSpace(textContent(n)))
+	if total == 0 {
+		return 0
+	}
+	return float64(len(linkText(n))) / float64(total)
+}
+
+// isBoilerplate reports whether n looks like a non-article container (a
+// comment thread, share bar, or related-posts block) based on its class/id,
+// for filtering elements nested inside the chosen content container.
+func isBoilerplate(n *html.Node) bool {
+	hint := attr(n, "class") + " " + attr(n, "id")
+	return unlikelyClassID.MatchString(hint) && !likelyClassID.MatchString(hint)
+}
+
+func renderChildren(buf *strings.Builder, n *html.Node) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		renderNode(buf, c)
+	}
+}
+
+func renderNode(buf *strings.Builder, n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		if text := strings.TrimSpace(n.Data); text != "" {
+			buf.WriteString(text)
+			buf.WriteString(" ")
+		}
+		return
+	case html.ElementNode:
+		if skipTags[n.Data] || isBoilerplate(n) {
+			return
+		}
+	default:
+		renderChildren(buf, n)
+		return
+	}
+
+	switch n.Data {
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		buf.WriteString("\n\n" + strings.Repeat("#", int(n.Data[1]-'0')) + " ")
+		renderChildren(buf, n)
+		buf.WriteString("\n\n")
+	case "p", "div", "article", "section":
+		buf.WriteString("\n\n")
+		renderChildren(buf, n)
+		buf.WriteString("\n\n")
+	case "li":
+		buf.WriteString("\n- ")
+		renderChildren(buf, n)
+	case "br":
+		buf.WriteString("\n")
+	case "a":
+		href := attr(n, "href")
+		buf.WriteString("[")
+		renderChildren(buf, n)
+		buf.WriteString("]")
+		if href != "" {
+			buf.WriteString("(" + href + ")")
+		}
+	case "img":
+		// images are dropped from the text export
+	default:
+		renderChildren(buf, n)
+	}
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// textContent concatenates all text under n, skipping skipTags subtrees.
+func textContent(n *html.Node) string {
+	var buf strings.Builder
+
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			buf.WriteString(n.Data)
+			return
+		}
+		if n.Type == html.ElementNode && skipTags[n.Data] {
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+
+	return buf.String()
+}
+
+// linkText concatenates the text of every <a> descendant of n.
+func linkText(n *html.Node) string {
+	var buf strings.Builder
+
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "a" {
+			buf.WriteString(textContent(n))
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+
+	return buf.String()
+}
+
+func findTitle(n *html.Node) string {
+	if node := findNode(n, "title"); node != nil && node.FirstChild != nil {
+		return strings.TrimSpace(node.FirstChild.Data)
+	}
+	return ""
+}
+
+func findNode(n *html.Node, tag string) *html.Node {
+	if n.Type == html.ElementNode && n.Data == tag {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := findNode(c, tag); found != nil {
+			return found
+		}
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package reader
+
+import (
+	"strings"
+	"testing"
+)
+
+const samplePage = `<html>
+<head><title>Sample Article</title></head>
+<body>
+<div class="sidebar">
+<ul>
+<li>Related post 1</li>
+<li>Related post 2</li>
+<li>Related post 3</li>
+</ul>
+</div>
+<div id="cookie-banner">We use cookies to improve your experience. Accept all cookies to continue browsing this site.</div>
+<article class="post-content">
+<h1>A Long Article About Gophers</h1>
+<p>Gophers are small, burrowing rodents that are known for their extensive tunnel systems and their habit of storing food underground for the winter months ahead.</p>
+<p>Unlike their larger cousins, pocket gophers spend almost their entire lives below ground, surfacing only rarely to forage for fresh vegetation near their burrow entrances.</p>
+<p>Their name comes from the external, fur-lined cheek pouches they use to carry food, which look a little like pockets sewn onto the sides of their face.</p>
+</article>
+<div class="comments">
+<p>Great article!</p>
+<p>Thanks for sharing, really enjoyed this read.</p>
+</div>
+</body>
+</html>`
+
+func TestParseDropsBoilerplate(t *testing.T) {
+	article, err := Parse(strings.NewReader(samplePage), "https://example.com/gophers")
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	if !strings.Contains(article.Markdown, "burrowing rodents") {
+		t.Errorf("Markdown = %q, want it to contain the article body", article.Markdown)
+	}
+
+	for _, unwanted := range []string{"Related post", "We use cookies", "Great article!"} {
+		if strings.Contains(article.Markdown, unwanted) {
+			t.Errorf("Markdown = %q, want it to NOT contain boilerplate %q", article.Markdown, unwanted)
+		}
+	}
+}
+
+func TestParseTitle(t *testing.T) {
+	article, err := Parse(strings.NewReader(samplePage), "https://example.com/gophers")
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	if article.Title != "Sample Article" {
+		t.Errorf("Title = %q, want %q", article.Title, "Sample Article")
+	}
+}